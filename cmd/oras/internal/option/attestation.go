@@ -0,0 +1,97 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package option
+
+import (
+	"encoding/json"
+	"os"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/pflag"
+)
+
+// discoveryPredicateType identifies the in-toto predicate written by
+// Attestation.Write: a tamper-evident record of what discover found attached
+// to a subject at a point in time.
+const discoveryPredicateType = "https://oras.land/attestations/discovery/v1"
+
+// Attestation enables writing out discover's results as a signed-ready
+// in-toto Statement, so CI systems can keep a tamper-evident record of what
+// was attached to an image and, if desired, push that record back as a new
+// referrer.
+type Attestation struct {
+	// Path is the file to write the in-toto Statement to. Empty disables
+	// attestation generation.
+	Path string
+}
+
+// ApplyFlags applies flags to a command flag set.
+func (opts *Attestation) ApplyFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&opts.Path, "attest", "", "write the discovered referrers as a signed-ready in-toto attestation to path")
+}
+
+// Enabled reports whether --attest was set.
+func (opts *Attestation) Enabled() bool {
+	return opts.Path != ""
+}
+
+// DiscoveredReferrer is one predicate entry: a referrer descriptor plus the
+// depth in the referrers graph at which discover found it.
+type DiscoveredReferrer struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Depth        int               `json:"depth"`
+}
+
+// discoveryPredicate is the predicate body of the in-toto Statement.
+type discoveryPredicate struct {
+	Referrers []DiscoveredReferrer `json:"referrers"`
+}
+
+// inTotoSubject is an in-toto Statement subject entry.
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// discoveryStatement is the in-toto Statement written by --attest.
+type discoveryStatement struct {
+	Type          string             `json:"_type"`
+	PredicateType string             `json:"predicateType"`
+	Subject       []inTotoSubject    `json:"subject"`
+	Predicate     discoveryPredicate `json:"predicate"`
+}
+
+// Write renders the discovery statement for subject and its discovered
+// referrers and writes it to opts.Path.
+func (opts *Attestation) Write(subjectRef string, subject ocispec.Descriptor, referrers []DiscoveredReferrer) error {
+	statement := discoveryStatement{
+		Type:          "https://in-toto.io/Statement/v1",
+		PredicateType: discoveryPredicateType,
+		Subject: []inTotoSubject{{
+			Name:   subjectRef,
+			Digest: map[string]string{subject.Digest.Algorithm().String(): subject.Digest.Encoded()},
+		}},
+		Predicate: discoveryPredicate{Referrers: referrers},
+	}
+	encoded, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(opts.Path, encoded, 0644)
+}
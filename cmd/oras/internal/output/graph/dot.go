@@ -0,0 +1,46 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dot renders a referrers graph as a Graphviz DOT document, with edges
+// pointing from each referrer to the subject it refers to.
+type Dot struct{}
+
+func (Dot) Render(root *Node) (string, error) {
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	seen := make(map[string]bool)
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		id := nodeID(n)
+		if !seen[id] {
+			seen[id] = true
+			fmt.Fprintf(&b, "    %q [label=%q];\n", id, label(n))
+		}
+		for _, child := range n.Children {
+			fmt.Fprintf(&b, "    %q -> %q;\n", nodeID(child), id)
+			walk(child)
+		}
+	}
+	walk(root)
+	b.WriteString("}\n")
+	return b.String(), nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package graph renders a referrers graph discovered by the discover command
+// into graph-oriented document formats (Mermaid, Graphviz DOT, ...).
+package graph
+
+import ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+// Node is one vertex of a referrers graph: a descriptor together with the
+// referrers discovered pointing at it.
+type Node struct {
+	ocispec.Descriptor
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Edge describes a "from referrer to subject" relationship in a flattened
+// view of the graph.
+type Edge struct {
+	From string             `json:"from"`
+	To   ocispec.Descriptor `json:"to"`
+}
+
+// Document is the flattened JSON representation of a referrers graph,
+// consumers can reconstruct the whole DAG from Root and Edges.
+type Document struct {
+	Root  ocispec.Descriptor `json:"root"`
+	Edges []Edge             `json:"edges"`
+}
+
+// Edges flattens the tree rooted at n into a deduplicated edge list.
+func (n *Node) Edges() []Edge {
+	var edges []Edge
+	seen := make(map[string]bool)
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		for _, child := range node.Children {
+			key := child.Digest.String() + "->" + node.Digest.String()
+			if !seen[key] {
+				seen[key] = true
+				edges = append(edges, Edge{From: child.Digest.String(), To: node.Descriptor})
+			}
+			walk(child)
+		}
+	}
+	walk(n)
+	return edges
+}
+
+// Renderer renders a referrers graph rooted at a Node into a textual graph
+// document. New formats (e.g. a future CycloneDX BOM-Link renderer) plug in
+// by implementing this interface.
+type Renderer interface {
+	// Render returns the rendered document for the graph rooted at root.
+	Render(root *Node) (string, error)
+}
+
+// Renderers maps an --output format name to its Renderer.
+var Renderers = map[string]Renderer{
+	"mermaid": Mermaid{},
+	"dot":     Dot{},
+}
+
+// nodeID returns a stable, graph-syntax-safe identifier for n, derived from
+// its digest's encoded value (digest algorithms disallow the characters that
+// would need escaping in Mermaid/DOT node ids).
+func nodeID(n *Node) string {
+	return n.Digest.Encoded()
+}
+
+// label is the human-readable node label: the first 12 characters of the
+// digest's encoded value plus the artifact type, if any, e.g.
+// "abcdef123456 application/vnd.example.sbom".
+func label(n *Node) string {
+	digest := n.Digest.Encoded()
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	if n.ArtifactType == "" {
+		return digest
+	}
+	return digest + " " + n.ArtifactType
+}
@@ -0,0 +1,505 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras/cmd/oras/internal/feature"
+	"oras.land/oras/cmd/oras/internal/option"
+	"oras.land/oras/cmd/oras/internal/output/graph"
+)
+
+// filtersAppliedHeaderer is implemented by registry repositories that can
+// report which query parameters the server actually honored on the last
+// Referrers call, per the distribution spec's OCI-Filters-Applied response
+// header. Repositories that can't report this (e.g. an OCI image layout,
+// which always applies filters locally) simply don't implement it.
+type filtersAppliedHeaderer interface {
+	ReferrersFiltersApplied() []string
+}
+
+// discoverOptions represents the flags and parameters for the discover command.
+type discoverOptions struct {
+	option.Common
+	option.Platform
+	option.Target
+
+	option.Attestation
+
+	artifactTypes  []string
+	outputType     string
+	depth          int
+	cycleDetection bool
+}
+
+// artifactTypeFilters is the parsed form of the repeatable --artifact-type
+// flag: a set of positive filters to OR together (each either an exact
+// media type or a glob pattern) and a set of negated filters to exclude.
+type artifactTypeFilters struct {
+	exact    []string
+	globs    []string
+	excludes []string
+}
+
+func parseArtifactTypeFilters(raw []string) artifactTypeFilters {
+	var f artifactTypeFilters
+	for _, v := range raw {
+		if rest, negated := strings.CutPrefix(v, "!"); negated {
+			f.excludes = append(f.excludes, rest)
+			continue
+		}
+		if strings.ContainsAny(v, "*?") {
+			f.globs = append(f.globs, v)
+		} else {
+			f.exact = append(f.exact, v)
+		}
+	}
+	return f
+}
+
+func (f artifactTypeFilters) hasPositive() bool {
+	return len(f.exact) > 0 || len(f.globs) > 0
+}
+
+func (f artifactTypeFilters) matches(artifactType string) bool {
+	if f.hasPositive() {
+		matched := false
+		for _, e := range f.exact {
+			if artifactType == e {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			for _, g := range f.globs {
+				if ok, _ := path.Match(g, artifactType); ok {
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, ex := range f.excludes {
+		if ok, _ := path.Match(ex, artifactType); ok || artifactType == ex {
+			return false
+		}
+	}
+	return true
+}
+
+// discoverNode is one vertex of the referrers graph rooted at the resolved
+// subject. Children are populated up to the requested depth.
+type discoverNode = graph.Node
+
+func discoverCmd() *cobra.Command {
+	var opts discoverOptions
+	cmd := &cobra.Command{
+		Use:   "discover [flags] <name>{:<tag>|@<digest>}",
+		Short: feature.Preview.Mark + " Discover referrers of a manifest in a registry or an OCI image layout",
+		Long: feature.Preview.Mark + ` Discover referrers of a manifest in a registry or an OCI image layout
+` + feature.Preview.Description + `
+Example - Discover direct referrers of manifest 'hello:v1' in registry 'localhost:5000':
+  oras discover localhost:5000/hello:v1
+
+Example - Discover the full referrers graph up to 3 levels deep:
+  oras discover --depth 3 localhost:5000/hello:v1
+
+Example - Discover the full referrers graph with no depth limit, guarding against cycles:
+  oras discover --depth 0 --cycle-detection localhost:5000/hello:v1
+
+Example - Record discovered referrers as an in-toto attestation for later review:
+  oras discover --attest discovery.json localhost:5000/hello:v1
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.RawReference = args[0]
+			return runDiscover(cmd.Context(), opts)
+		},
+	}
+	cmd.Flags().StringArrayVarP(&opts.artifactTypes, "artifact-type", "", nil, "filter referrers by artifact type; repeat for OR semantics, prefix with ! to exclude, glob patterns allowed")
+	cmd.Flags().StringVarP(&opts.outputType, "output", "o", "tree", "format in which to display referrers (table, json, tree, mermaid, or dot)")
+	cmd.Flags().IntVar(&opts.depth, "depth", 1, "maximum depth of the referrers graph to walk, 0 means unbounded")
+	cmd.Flags().BoolVar(&opts.cycleDetection, "cycle-detection", false, "guard against cycles when walking a deep or unbounded referrers graph")
+	opts.Attestation.ApplyFlags(cmd.Flags())
+	option.ApplyFlags(&opts, cmd.Flags())
+	return cmd
+}
+
+// runDiscover resolves the subject and walks its referrers graph to the
+// requested depth, rendering the result in the requested output format.
+func runDiscover(ctx context.Context, opts discoverOptions) error {
+	repo, err := opts.NewReadonlyTarget(ctx)
+	if err != nil {
+		return err
+	}
+	subject, err := repo.Resolve(ctx, opts.RawReference)
+	if err != nil {
+		return err
+	}
+
+	filters := parseArtifactTypeFilters(opts.artifactTypes)
+	root, depths, err := walkReferrers(ctx, repo, subject, filters, opts.Platform.Platform, opts.depth, opts.cycleDetection)
+	if err != nil {
+		return err
+	}
+
+	if opts.Attestation.Enabled() {
+		if err := opts.Attestation.Write(opts.RawReference, subject, discoveredReferrers(root, depths)); err != nil {
+			return err
+		}
+	}
+
+	if opts.depth == 1 && opts.outputType != "mermaid" && opts.outputType != "dot" {
+		return printDiscoveredReferrers(subject, root.Children, opts.outputType, opts.Verbose)
+	}
+	return printDiscoveredGraph(subject, root, opts.outputType, opts.Verbose)
+}
+
+// referrerFetcher abstracts the single oras-go call needed to enumerate the
+// direct referrers of a descriptor so it can be shared between the registry
+// and OCI layout code paths.
+type referrerFetcher interface {
+	Referrers(ctx context.Context, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error
+}
+
+// walkReferrers performs a single breadth-first traversal of the referrers
+// graph rooted at subject, caching each digest's direct referrers so diamond
+// shaped graphs (e.g. a signature signing both an image and its SBOM) only
+// fetch once. depth == 0 means unbounded; depth == 1 preserves the original
+// direct-referrers-only behavior.
+func walkReferrers(ctx context.Context, repo referrerFetcher, subject ocispec.Descriptor, filters artifactTypeFilters, platform *ocispec.Platform, depth int, cycleDetection bool) (*discoverNode, map[string]int, error) {
+	root := &discoverNode{Descriptor: subject}
+	depths := map[string]int{subject.Digest.String(): 0}
+	cache := map[string][]ocispec.Descriptor{}
+	warnedFallback := false
+	var fetch func(desc ocispec.Descriptor) ([]ocispec.Descriptor, error)
+	fetch = func(desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		key := desc.Digest.String()
+		if cached, ok := cache[key]; ok {
+			return cached, nil
+		}
+		referrers, warn, err := fetchReferrers(ctx, repo, desc, filters)
+		if err != nil {
+			return nil, err
+		}
+
+		if warn && !warnedFallback {
+			fmt.Fprintln(os.Stderr, "warning: registry did not apply artifactType filter; filtered locally")
+			warnedFallback = true
+		}
+		referrers = filterByArtifactTypeFilters(referrers, filters)
+		if platform != nil {
+			referrers = filterByPlatform(referrers, platform)
+		}
+		cache[key] = referrers
+		return referrers, nil
+	}
+
+	type queued struct {
+		node     *discoverNode
+		level    int
+		ancestry map[string]bool
+	}
+	queue := []queued{{node: root, level: 0, ancestry: map[string]bool{subject.Digest.String(): true}}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if depth != 0 && current.level >= depth {
+			continue
+		}
+		referrers, err := fetch(current.node.Descriptor)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range referrers {
+			if cycleDetection && current.ancestry[r.Digest.String()] {
+				continue
+			}
+			child := &discoverNode{Descriptor: r}
+			current.node.Children = append(current.node.Children, child)
+			if _, seen := depths[r.Digest.String()]; !seen {
+				depths[r.Digest.String()] = current.level + 1
+			}
+			nextAncestry := current.ancestry
+			if cycleDetection {
+				nextAncestry = make(map[string]bool, len(current.ancestry)+1)
+				for k := range current.ancestry {
+					nextAncestry[k] = true
+				}
+				nextAncestry[r.Digest.String()] = true
+			}
+			queue = append(queue, queued{node: child, level: current.level + 1, ancestry: nextAncestry})
+		}
+	}
+	return root, depths, nil
+}
+
+// fetchReferrers issues one Referrers call per exact positive artifactType
+// filter, fanned out in parallel since the Referrers API only accepts a
+// single artifactType per request, and dedupes the results by digest. An
+// extra unfiltered call is folded in whenever there is no exact filter or
+// there are glob filters, since those can only be evaluated client-side;
+// negated filters are applied client-side to whatever the calls return. It
+// reports whether
+// any registry call ignored the requested artifactType per
+// OCI-Filters-Applied, so the caller can warn once.
+//
+// Known limitation: filtersAppliedHeaderer reports only the *last* call's
+// applied filters as mutable state shared on repo, so for a repo that
+// implements it, the calls that need to read that header (every exact
+// artifactType filter) are serialized against each other via headerMu --
+// there's no way to keep both correctness and full concurrency against a
+// "last call wins" contract. Repos that don't implement filtersAppliedHeaderer
+// have nothing racy to read and keep full fan-out concurrency, as does the
+// header-check-free unfiltered call.
+func fetchReferrers(ctx context.Context, repo referrerFetcher, desc ocispec.Descriptor, filters artifactTypeFilters) ([]ocispec.Descriptor, bool, error) {
+	types := append([]string{}, filters.exact...)
+	if len(filters.exact) == 0 || len(filters.globs) > 0 {
+		types = append(types, "")
+	}
+	headerer, hasHeaderer := repo.(filtersAppliedHeaderer)
+
+	var (
+		mu        sync.Mutex
+		headerMu  sync.Mutex
+		wg        sync.WaitGroup
+		referrers []ocispec.Descriptor
+		warn      bool
+		firstErr  error
+	)
+	for _, t := range types {
+		wg.Add(1)
+		go func(artifactType string) {
+			defer wg.Done()
+			needsHeaderCheck := artifactType != "" && hasHeaderer
+			if needsHeaderCheck {
+				headerMu.Lock()
+				defer headerMu.Unlock()
+			}
+			var rs []ocispec.Descriptor
+			err := repo.Referrers(ctx, desc, artifactType, func(batch []ocispec.Descriptor) error {
+				rs = append(rs, batch...)
+				return nil
+			})
+			var gotWarn bool
+			if err == nil && needsHeaderCheck && !containsFilter(headerer.ReferrersFiltersApplied(), "artifactType") {
+				rs = filterByArtifactType(rs, artifactType)
+				gotWarn = true
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			if gotWarn {
+				warn = true
+			}
+			referrers = append(referrers, rs...)
+		}(t)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
+	return dedupeByDigest(referrers), warn, nil
+}
+
+func dedupeByDigest(referrers []ocispec.Descriptor) []ocispec.Descriptor {
+	seen := make(map[string]bool, len(referrers))
+	deduped := make([]ocispec.Descriptor, 0, len(referrers))
+	for _, r := range referrers {
+		key := r.Digest.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, r)
+	}
+	return deduped
+}
+
+// filterByArtifactTypeFilters applies the full OR/negation/glob filter set
+// client-side; it's a no-op pass-through filter when no --artifact-type was
+// given.
+func filterByArtifactTypeFilters(referrers []ocispec.Descriptor, filters artifactTypeFilters) []ocispec.Descriptor {
+	var filtered []ocispec.Descriptor
+	for _, r := range referrers {
+		if filters.matches(r.ArtifactType) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// discoveredReferrers flattens the referrers graph rooted at root (excluding
+// the root itself) into the attestation predicate entries, tagging each with
+// the depth at which it was first discovered.
+func discoveredReferrers(root *discoverNode, depths map[string]int) []option.DiscoveredReferrer {
+	var referrers []option.DiscoveredReferrer
+	seen := map[string]bool{}
+	var walk func(n *discoverNode)
+	walk = func(n *discoverNode) {
+		for _, child := range n.Children {
+			key := child.Digest.String()
+			if !seen[key] {
+				seen[key] = true
+				referrers = append(referrers, option.DiscoveredReferrer{
+					Digest:       child.Digest.String(),
+					MediaType:    child.MediaType,
+					ArtifactType: child.ArtifactType,
+					Annotations:  child.Annotations,
+					Depth:        depths[key],
+				})
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+	return referrers
+}
+
+func matchPlatform(desc ocispec.Descriptor, platform *ocispec.Platform) bool {
+	if desc.Platform == nil {
+		return false
+	}
+	return desc.Platform.OS == platform.OS && desc.Platform.Architecture == platform.Architecture
+}
+
+func filterByPlatform(referrers []ocispec.Descriptor, platform *ocispec.Platform) []ocispec.Descriptor {
+	var filtered []ocispec.Descriptor
+	for _, r := range referrers {
+		if matchPlatform(r, platform) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// filterByArtifactType is the client-side fallback used when a registry
+// ignores the artifactType query parameter on Referrers, signalled via a
+// missing "artifactType" entry in its OCI-Filters-Applied response header.
+func filterByArtifactType(referrers []ocispec.Descriptor, artifactType string) []ocispec.Descriptor {
+	var filtered []ocispec.Descriptor
+	for _, r := range referrers {
+		if r.ArtifactType == artifactType {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func containsFilter(applied []string, name string) bool {
+	for _, a := range applied {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// printDiscoveredGraph renders a referrers graph walked to any depth other
+// than one, and the mermaid and dot formats at every depth. Unlike printDiscoveredReferrers, it has no tabular representation: a
+// table's flat rows can't convey which referrer refers to which ancestor, so
+// "table" is rejected outright rather than silently falling back to the
+// json graph document.
+func printDiscoveredGraph(subject ocispec.Descriptor, root *discoverNode, format string, verbose bool) error {
+	if renderer, ok := graph.Renderers[format]; ok {
+		rendered, err := renderer.Render(root)
+		if err != nil {
+			return err
+		}
+		fmt.Println(rendered)
+		return nil
+	}
+	switch format {
+	case "tree":
+		return printDiscoverTree(root, verbose)
+	case "json":
+		doc := graph.Document{Root: subject, Edges: root.Edges()}
+		encoded, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+		return nil
+	default:
+		return fmt.Errorf("output format %q does not support --depth other than 1; use json, tree, mermaid, or dot", format)
+	}
+}
+
+func printDiscoverTree(node *discoverNode, verbose bool) error {
+	var print func(n *discoverNode, prefix string)
+	print = func(n *discoverNode, prefix string) {
+		fmt.Printf("%s%s %s\n", prefix, n.Digest, n.ArtifactType)
+		if verbose {
+			for k, v := range n.Annotations {
+				fmt.Printf("%s  %s=%s\n", prefix, k, v)
+			}
+		}
+		for _, child := range n.Children {
+			print(child, prefix+"  ")
+		}
+	}
+	print(node, "")
+	return nil
+}
+
+func printDiscoveredReferrers(subject ocispec.Descriptor, referrers []*discoverNode, format string, verbose bool) error {
+	descs := make([]ocispec.Descriptor, len(referrers))
+	for i, r := range referrers {
+		descs[i] = r.Descriptor
+	}
+	switch format {
+	case "json":
+		encoded, err := json.MarshalIndent(ocispec.Index{Manifests: descs}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+	case "table":
+		fmt.Println(subject.Digest)
+		fmt.Printf("%-15s %s\n", "Digest", "Artifact Type")
+		for _, d := range descs {
+			fmt.Printf("%-15s %s\n", d.Digest, d.ArtifactType)
+		}
+	default: // tree
+		fmt.Println(subject.Digest)
+		for _, d := range descs {
+			fmt.Printf("└── %s %s\n", d.Digest, d.ArtifactType)
+			if verbose {
+				for k, v := range d.Annotations {
+					fmt.Printf("    %s=%s\n", k, v)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -17,13 +17,20 @@ package command
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	digest "github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"gopkg.in/yaml.v2"
 	"oras.land/oras/test/e2e/internal/testdata/feature"
@@ -32,6 +39,109 @@ import (
 	. "oras.land/oras/test/e2e/internal/utils"
 )
 
+// mockReferrersRegistry serves a referrers index listing the given referrers
+// for subjectDigest, optionally reporting (via the OCI-Filters-Applied header)
+// that it already honored the artifactType query parameter so the discover
+// command's client-side fallback filtering can be exercised both ways. It
+// also answers the manifest HEAD request that Resolve issues before
+// discover ever gets to call Referrers.
+func mockReferrersRegistry(subjectDigest string, referrers []ocispec.Descriptor, reportFiltersApplied bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/manifests/"):
+			w.Header().Set("Docker-Content-Digest", subjectDigest)
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+			w.Header().Set("Content-Length", "2")
+		case strings.Contains(r.URL.Path, "referrers"):
+			if reportFiltersApplied && r.URL.Query().Get("artifactType") != "" {
+				w.Header().Set("OCI-Filters-Applied", "artifactType")
+			}
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+			index := ocispec.Index{Manifests: referrers}
+			encoded, _ := json.Marshal(index)
+			w.Write(encoded)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// mockNestedReferrersRegistry serves a genuine two-level referrers graph:
+// subject has one direct referrer, an SBOM, and that SBOM itself has one
+// referrer, a signature over the SBOM. Unlike the foobar fixtures (all
+// direct referrers of the same subject), walking past the SBOM to find its
+// signature proves discover actually recurses rather than just listing
+// direct referrers repeatedly.
+func mockNestedReferrersRegistry(repo string) (server *httptest.Server, subject, sbom, sbomSignature ocispec.Descriptor) {
+	sbom = syntheticDescriptor("application/vnd.example.sbom", []byte(`{"name":"sbom"}`))
+	sbomSignature = syntheticDescriptor("application/vnd.example.signature", []byte(`{"name":"sbom-signature"}`))
+	subject = syntheticDescriptor(ocispec.MediaTypeImageManifest, []byte(`{"name":"subject"}`))
+
+	referrersByDigest := map[string][]ocispec.Descriptor{
+		subject.Digest.String(): {sbom},
+		sbom.Digest.String():    {sbomSignature},
+	}
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/manifests/"):
+			w.Header().Set("Docker-Content-Digest", subject.Digest.String())
+			w.Header().Set("Content-Type", subject.MediaType)
+			w.Header().Set("Content-Length", strconv.FormatInt(subject.Size, 10))
+		case strings.Contains(r.URL.Path, "referrers"):
+			dgst := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+			encoded, _ := json.Marshal(ocispec.Index{Manifests: referrersByDigest[dgst]})
+			w.Write(encoded)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, subject, sbom, sbomSignature
+}
+
+func syntheticDescriptor(mediaType string, content []byte) ocispec.Descriptor {
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(content),
+		Size:      int64(len(content)),
+	}
+}
+
+// mockCyclicReferrersRegistry serves a genuine cycle: subject has one
+// referrer, referrerA, which has one referrer, referrerB, which in turn
+// refers back to subject. This proves --cycle-detection actually guards
+// against a graph that loops back on itself, rather than merely terminating
+// on a fixture with no cycle to begin with.
+func mockCyclicReferrersRegistry(repo string) (server *httptest.Server, subject, referrerA, referrerB ocispec.Descriptor) {
+	referrerA = syntheticDescriptor("application/vnd.example.a", []byte(`{"name":"referrer-a"}`))
+	referrerB = syntheticDescriptor("application/vnd.example.b", []byte(`{"name":"referrer-b"}`))
+	subject = syntheticDescriptor(ocispec.MediaTypeImageManifest, []byte(`{"name":"cyclic-subject"}`))
+
+	referrersByDigest := map[string][]ocispec.Descriptor{
+		subject.Digest.String():   {referrerA},
+		referrerA.Digest.String(): {referrerB},
+		referrerB.Digest.String(): {subject},
+	}
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/manifests/"):
+			w.Header().Set("Docker-Content-Digest", subject.Digest.String())
+			w.Header().Set("Content-Type", subject.MediaType)
+			w.Header().Set("Content-Length", strconv.FormatInt(subject.Size, 10))
+		case strings.Contains(r.URL.Path, "referrers"):
+			dgst := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+			encoded, _ := json.Marshal(ocispec.Index{Manifests: referrersByDigest[dgst]})
+			w.Write(encoded)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, subject, referrerA, referrerB
+}
+
 func discoverKeyWords(verbose bool, descs ...ocispec.Descriptor) []string {
 	var ret []string
 	for _, d := range descs {
@@ -120,6 +230,7 @@ var _ = Describe("Common registry users:", func() {
 
 		})
 	})
+
 	When("running discover command with table output", func() {
 		format := "table"
 		It("should all referrers of a subject", func() {
@@ -129,6 +240,163 @@ var _ = Describe("Common registry users:", func() {
 				Exec()
 		})
 	})
+
+	When("running discover command with --depth", func() {
+		It("should only show direct referrers when --depth is not set", func() {
+			bytes := ORAS("discover", subjectRef, "-o", "json").Exec().Out.Contents()
+			var index ocispec.Index
+			Expect(json.Unmarshal(bytes, &index)).ShouldNot(HaveOccurred())
+			Expect(index.Manifests).To(HaveLen(2))
+		})
+
+		It("should discover a referrer-of-a-referrer when --depth allows recursion", func() {
+			server, subject, sbom, sbomSignature := mockNestedReferrersRegistry(ArtifactRepo)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, subject.Digest)
+			ORAS("discover", ref, "-o", "tree", "--depth", "2", Flags.Insecure, Flags.PlainHTTP).
+				MatchKeyWords(sbom.Digest.String(), sbomSignature.Digest.String()).
+				Exec()
+		})
+
+		It("should not descend into a referrer-of-a-referrer when --depth is 1", func() {
+			server, subject, sbom, sbomSignature := mockNestedReferrersRegistry(ArtifactRepo)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, subject.Digest)
+			out := ORAS("discover", ref, "-o", "tree", Flags.Insecure, Flags.PlainHTTP).
+				MatchKeyWords(sbom.Digest.String()).
+				Exec().Out.Contents()
+			Expect(string(out)).NotTo(ContainSubstring(sbomSignature.Digest.Encoded()))
+		})
+
+		It("should emit a graph document with root and edges when depth > 1", func() {
+			bytes := ORAS("discover", subjectRef, "-o", "json", "--depth", "0", "--cycle-detection").Exec().Out.Contents()
+			var doc struct {
+				Root  ocispec.Descriptor `json:"root"`
+				Edges []struct {
+					From string             `json:"from"`
+					To   ocispec.Descriptor `json:"to"`
+				} `json:"edges"`
+			}
+			Expect(json.Unmarshal(bytes, &doc)).ShouldNot(HaveOccurred())
+			Expect(doc.Root.Digest.String()).To(Equal(foobar.Digest))
+			Expect(len(doc.Edges)).To(BeNumerically(">=", 2))
+		})
+
+		It("should not loop forever on a cyclic referrers graph when --cycle-detection is set", func() {
+			server, subject, referrerA, referrerB := mockCyclicReferrersRegistry(ArtifactRepo)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, subject.Digest)
+			out := ORAS("discover", ref, "-o", "tree", "--depth", "0", "--cycle-detection", Flags.Insecure, Flags.PlainHTTP).
+				MatchKeyWords(referrerA.Digest.String(), referrerB.Digest.String()).
+				Exec().Out.Contents()
+			Expect(strings.Count(string(out), referrerA.Digest.Encoded())).To(Equal(1))
+			Expect(strings.Count(string(out), referrerB.Digest.Encoded())).To(Equal(1))
+		})
+	})
+
+	When("running discover command with repeatable --artifact-type", func() {
+		It("should OR together multiple positive artifact types", func() {
+			// the signature refers to the SBOM rather than to the subject, so
+			// the whole graph is walked to reach it
+			bytes := ORAS("discover", subjectRef, "-o", "json", "--depth", "0", "--cycle-detection",
+				"--artifact-type", foobar.SBOMImageReferrer.ArtifactType,
+				"--artifact-type", foobar.SignatureImageReferrer.ArtifactType).Exec().Out.Contents()
+			var doc struct {
+				Edges []struct {
+					From string `json:"from"`
+				} `json:"edges"`
+			}
+			Expect(json.Unmarshal(bytes, &doc)).ShouldNot(HaveOccurred())
+			var referrers []string
+			for _, e := range doc.Edges {
+				referrers = append(referrers, e.From)
+			}
+			Expect(referrers).Should(ContainElement(foobar.SBOMImageReferrer.Digest.String()))
+			Expect(referrers).Should(ContainElement(foobar.SignatureImageReferrer.Digest.String()))
+		})
+
+		It("should exclude referrers matching a negated filter", func() {
+			bytes := ORAS("discover", subjectRef, "-o", "json", "--depth", "0", "--cycle-detection", "--artifact-type", "!"+foobar.SignatureImageReferrer.ArtifactType).Exec().Out.Contents()
+			var doc struct {
+				Edges []struct {
+					From string `json:"from"`
+				} `json:"edges"`
+			}
+			Expect(json.Unmarshal(bytes, &doc)).ShouldNot(HaveOccurred())
+			var referrers []string
+			for _, e := range doc.Edges {
+				referrers = append(referrers, e.From)
+			}
+			Expect(referrers).ShouldNot(ContainElement(foobar.SignatureImageReferrer.Digest.String()))
+		})
+
+		It("should support glob patterns for negated filters", func() {
+			artifactType := foobar.SignatureImageReferrer.ArtifactType
+			glob := "!" + artifactType[:strings.LastIndex(artifactType, "/")] + "/*"
+			bytes := ORAS("discover", subjectRef, "-o", "json", "--depth", "0", "--cycle-detection", "--artifact-type", glob).Exec().Out.Contents()
+			var doc struct {
+				Edges []struct {
+					From string `json:"from"`
+				} `json:"edges"`
+			}
+			Expect(json.Unmarshal(bytes, &doc)).ShouldNot(HaveOccurred())
+			var referrers []string
+			for _, e := range doc.Edges {
+				referrers = append(referrers, e.From)
+			}
+			Expect(referrers).ShouldNot(ContainElement(foobar.SignatureImageReferrer.Digest.String()))
+		})
+	})
+
+	When("running discover command with graph output formats", func() {
+		It("should render a Mermaid graph TD document with a node per referrer", func() {
+			out := ORAS("discover", subjectRef, "-o", "mermaid").Exec().Out.Contents()
+			Expect(string(out)).Should(ContainSubstring("graph TD"))
+			Expect(string(out)).Should(ContainSubstring(foobar.SBOMImageReferrer.Digest.Encoded()))
+			Expect(string(out)).Should(ContainSubstring(foobar.SBOMImageReferrer.Digest.Encoded() + " --> " + foobar.Digest[strings.LastIndex(foobar.Digest, ":")+1:]))
+		})
+
+		It("should render a Graphviz DOT document with a node per referrer", func() {
+			out := ORAS("discover", subjectRef, "-o", "dot").Exec().Out.Contents()
+			Expect(string(out)).Should(ContainSubstring("digraph {"))
+			Expect(string(out)).Should(ContainSubstring(foobar.SBOMImageReferrer.Digest.Encoded()))
+		})
+	})
+
+	When("running discover command with --attest", func() {
+		It("should write an in-toto discovery attestation alongside the normal output", func() {
+			attestPath := filepath.Join(GinkgoT().TempDir(), "discovery.json")
+			ORAS("discover", subjectRef, "-o", "json", "--depth", "0", "--cycle-detection", "--attest", attestPath).Exec()
+
+			raw, err := os.ReadFile(attestPath)
+			Expect(err).ShouldNot(HaveOccurred())
+			var statement struct {
+				Type          string `json:"_type"`
+				PredicateType string `json:"predicateType"`
+				Subject       []struct {
+					Name   string            `json:"name"`
+					Digest map[string]string `json:"digest"`
+				} `json:"subject"`
+				Predicate struct {
+					Referrers []struct {
+						Digest       string `json:"digest"`
+						ArtifactType string `json:"artifactType"`
+						Depth        int    `json:"depth"`
+					} `json:"referrers"`
+				} `json:"predicate"`
+			}
+			Expect(json.Unmarshal(raw, &statement)).ShouldNot(HaveOccurred())
+			Expect(statement.PredicateType).To(Equal("https://oras.land/attestations/discovery/v1"))
+			Expect(statement.Subject).To(HaveLen(1))
+			Expect(statement.Subject[0].Digest["sha256"]).To(Equal(foobar.Digest[strings.LastIndex(foobar.Digest, ":")+1:]))
+
+			var digests []string
+			for _, r := range statement.Predicate.Referrers {
+				digests = append(digests, r.Digest)
+			}
+			Expect(digests).Should(ContainElements(foobar.SBOMImageReferrer.Digest.String(), foobar.SignatureImageReferrer.Digest.String()))
+		})
+	})
 })
 
 var _ = Describe("Fallback registry users:", func() {
@@ -179,6 +447,69 @@ var _ = Describe("Fallback registry users:", func() {
 	})
 })
 
+var _ = Describe("Registry users against a filter-unaware server:", func() {
+	When("the registry omits the OCI-Filters-Applied header", func() {
+		It("should fall back to client-side artifactType filtering and warn via json output", func() {
+			server := mockReferrersRegistry(foobar.Digest, []ocispec.Descriptor{foobar.SBOMImageReferrer, foobar.SignatureImageReferrer}, false)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, foobar.Digest)
+			ORAS("discover", ref, "-o", "json", "--artifact-type", foobar.SBOMImageReferrer.ArtifactType, Flags.Insecure, Flags.PlainHTTP).
+				MatchErrKeyWords("warning: registry did not apply artifactType filter; filtered locally").
+				Exec()
+		})
+
+		It("should fall back to client-side artifactType filtering and warn via table output", func() {
+			server := mockReferrersRegistry(foobar.Digest, []ocispec.Descriptor{foobar.SBOMImageReferrer, foobar.SignatureImageReferrer}, false)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, foobar.Digest)
+			ORAS("discover", ref, "-o", "table", "--artifact-type", foobar.SBOMImageReferrer.ArtifactType, Flags.Insecure, Flags.PlainHTTP).
+				MatchKeyWords(foobar.SBOMImageReferrer.Digest.String()).
+				MatchErrKeyWords("warning: registry did not apply artifactType filter; filtered locally").
+				Exec()
+		})
+
+		It("should fall back to client-side artifactType filtering and warn via tree output", func() {
+			server := mockReferrersRegistry(foobar.Digest, []ocispec.Descriptor{foobar.SBOMImageReferrer, foobar.SignatureImageReferrer}, false)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, foobar.Digest)
+			ORAS("discover", ref, "-o", "tree", "--artifact-type", foobar.SBOMImageReferrer.ArtifactType, Flags.Insecure, Flags.PlainHTTP).
+				MatchKeyWords(foobar.SBOMImageReferrer.Digest.String()).
+				MatchErrKeyWords("warning: registry did not apply artifactType filter; filtered locally").
+				Exec()
+		})
+	})
+
+	When("the registry reports the artifactType filter was applied", func() {
+		It("should not filter again locally or warn via json output", func() {
+			server := mockReferrersRegistry(foobar.Digest, []ocispec.Descriptor{foobar.SBOMImageReferrer}, true)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, foobar.Digest)
+			out := ORAS("discover", ref, "-o", "json", "--artifact-type", foobar.SBOMImageReferrer.ArtifactType, Flags.Insecure, Flags.PlainHTTP).Exec().Err
+			Expect(out).NotTo(gbytes.Say("filtered locally"))
+		})
+
+		It("should not filter again locally or warn via table output", func() {
+			server := mockReferrersRegistry(foobar.Digest, []ocispec.Descriptor{foobar.SBOMImageReferrer}, true)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, foobar.Digest)
+			session := ORAS("discover", ref, "-o", "table", "--artifact-type", foobar.SBOMImageReferrer.ArtifactType, Flags.Insecure, Flags.PlainHTTP).
+				MatchKeyWords(foobar.SBOMImageReferrer.Digest.String()).
+				Exec()
+			Expect(session.Err).NotTo(gbytes.Say("filtered locally"))
+		})
+
+		It("should not filter again locally or warn via tree output", func() {
+			server := mockReferrersRegistry(foobar.Digest, []ocispec.Descriptor{foobar.SBOMImageReferrer}, true)
+			defer server.Close()
+			ref := fmt.Sprintf("%s/%s@%s", strings.TrimPrefix(server.URL, "http://"), ArtifactRepo, foobar.Digest)
+			session := ORAS("discover", ref, "-o", "tree", "--artifact-type", foobar.SBOMImageReferrer.ArtifactType, Flags.Insecure, Flags.PlainHTTP).
+				MatchKeyWords(foobar.SBOMImageReferrer.Digest.String()).
+				Exec()
+			Expect(session.Err).NotTo(gbytes.Say("filtered locally"))
+		})
+	})
+})
+
 var _ = Describe("OCI image layout users:", func() {
 	prepare := func(subjectRef string) {
 		ORAS("cp", RegistryRef(Host, ArtifactRepo, foobar.Tag), subjectRef, Flags.ToLayout, "-r").
@@ -266,4 +597,38 @@ var _ = Describe("OCI image layout users:", func() {
 			Expect(out).NotTo(gbytes.Say(foobar.SBOMImageReferrer.Digest.String()))
 		})
 	})
+
+	When("running discover command with --attest", func() {
+		It("should write an in-toto discovery attestation alongside the normal output", func() {
+			// prepare
+			subjectRef := LayoutRef(GinkgoT().TempDir(), foobar.Tag)
+			prepare(subjectRef)
+			attestPath := filepath.Join(GinkgoT().TempDir(), "discovery.json")
+			ORAS("discover", subjectRef, "-o", "json", "--depth", "0", "--cycle-detection", "--attest", attestPath, Flags.Layout).Exec()
+
+			raw, err := os.ReadFile(attestPath)
+			Expect(err).ShouldNot(HaveOccurred())
+			var statement struct {
+				PredicateType string `json:"predicateType"`
+				Subject       []struct {
+					Digest map[string]string `json:"digest"`
+				} `json:"subject"`
+				Predicate struct {
+					Referrers []struct {
+						Digest string `json:"digest"`
+					} `json:"referrers"`
+				} `json:"predicate"`
+			}
+			Expect(json.Unmarshal(raw, &statement)).ShouldNot(HaveOccurred())
+			Expect(statement.PredicateType).To(Equal("https://oras.land/attestations/discovery/v1"))
+			Expect(statement.Subject).To(HaveLen(1))
+			Expect(statement.Subject[0].Digest["sha256"]).To(Equal(foobar.Digest[strings.LastIndex(foobar.Digest, ":")+1:]))
+
+			var digests []string
+			for _, r := range statement.Predicate.Referrers {
+				digests = append(digests, r.Digest)
+			}
+			Expect(digests).Should(ContainElements(foobar.SBOMImageReferrer.Digest.String(), foobar.SignatureImageReferrer.Digest.String()))
+		})
+	})
 })